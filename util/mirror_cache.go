@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// repoAge is the contents of REPO_AGE_FILE: the last time repomd.xml was
+// refreshed from the mirror, and the revision it had at that time.
+type repoAge struct {
+	RefreshedAt int64  `json:"refreshed_at"`
+	Revision    string `json:"revision"`
+}
+
+// SetCacheDir overrides the on-disk directory the mirror caches repomd.xml,
+// primary.xml.gz and REPO_AGE_FILE under. By default the cache lives at
+// $XDG_CACHE_HOME/obshell-sdk/mirrors/<name>/ (see os.UserCacheDir).
+//
+// Since Mirror is a value type, this only takes effect for the receiver it's
+// called on. To reconfigure one of the default mirrors consulted by
+// DownloadPackage/SearchPackage/ResolvePackage, fetch the registry's own copy
+// first: DefaultMirrorRegistry.Get(OB_COMMUNITY_STABLE_MIRROR.Name()).
+func (m *Mirror) SetCacheDir(dir string) {
+	m.cacheDir = dir
+}
+
+// SetMaxAge controls how long a cached repomd.xml is trusted before Search
+// fetches a fresh copy. A zero max age (the default) disables age-based
+// caching: every Search re-fetches repomd.xml, though primary.xml.gz is still
+// reused whenever its checksum has not changed.
+//
+// Since Mirror is a value type, this only takes effect for the receiver it's
+// called on. To reconfigure one of the default mirrors consulted by
+// DownloadPackage/SearchPackage/ResolvePackage, fetch the registry's own copy
+// first: DefaultMirrorRegistry.Get(OB_COMMUNITY_STABLE_MIRROR.Name()).
+func (m *Mirror) SetMaxAge(d time.Duration) {
+	m.maxAge = d
+}
+
+func (m Mirror) cacheRoot() string {
+	if m.cacheDir != "" {
+		return m.cacheDir
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "obshell-sdk", "mirrors", m.name)
+}
+
+func (m Mirror) cachePath(name string) string {
+	return filepath.Join(m.cacheRoot(), name)
+}
+
+func (m Mirror) readAge() (repoAge, bool) {
+	var age repoAge
+	data, err := os.ReadFile(m.cachePath(REPO_AGE_FILE))
+	if err != nil {
+		return age, false
+	}
+	if err := json.Unmarshal(data, &age); err != nil {
+		return age, false
+	}
+	return age, true
+}
+
+func (m Mirror) writeAge(age repoAge) error {
+	data, err := json.Marshal(age)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.cachePath(REPO_AGE_FILE), data, 0644)
+}
+
+// Refresh fetches repomd.xml from the mirror and updates the on-disk cache.
+// If force is false and the cached repomd is younger than the mirror's max
+// age, Refresh is a no-op. Callers that just want the current metadata
+// (without forcing a round-trip) should call Search, which refreshes lazily.
+func (m Mirror) Refresh(force bool) error {
+	_, err := m.refreshRepomd(force)
+	return err
+}
+
+// refreshRepomd returns the trusted bytes of repomd.xml, serving them from
+// the on-disk cache when it is still within the mirror's max age.
+func (m Mirror) refreshRepomd(force bool) ([]byte, error) {
+	cachePath := m.cachePath("repomd.xml")
+
+	if !force && m.maxAge > 0 {
+		if age, ok := m.readAge(); ok {
+			if time.Since(time.Unix(age.RefreshedAt, 0)) < m.maxAge {
+				if body, err := os.ReadFile(cachePath); err == nil {
+					return body, nil
+				}
+			}
+		}
+	}
+
+	url, err := m.getRepomdUrl()
+	if err != nil {
+		return nil, err
+	}
+	resq, err := m.newRequest().Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	body := resq.Body()
+	if err := m.verifyRepomdSignature(body); err != nil {
+		return nil, err
+	}
+
+	var repo repoMD
+	if err := xml.Unmarshal(body, &repo); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(m.cacheRoot(), 0755); err == nil {
+		_ = os.WriteFile(cachePath, body, 0644)
+		_ = m.writeAge(repoAge{RefreshedAt: time.Now().Unix(), Revision: repo.Revision})
+	}
+	return body, nil
+}
+
+// loadCachedRepoFile returns the bytes of a repomd-referenced file (e.g.
+// primary.xml.gz), serving them from the on-disk cache whenever the cached
+// copy's checksum still matches what repomd.xml currently advertises, and
+// only hitting the network when it has actually changed.
+func (m Mirror) loadCachedRepoFile(name string, location Location, checksum repoChecksum) ([]byte, error) {
+	path := m.cachePath(name)
+	if cached, err := os.ReadFile(path); err == nil {
+		if verifyChecksum(cached, checksum) == nil {
+			return cached, nil
+		}
+	}
+
+	url, err := m.getLocalUrl(location)
+	if err != nil {
+		return nil, err
+	}
+	resq, err := m.newRequest().Get(url)
+	if err != nil {
+		return nil, err
+	}
+
+	body := resq.Body()
+	if err := verifyChecksum(body, checksum); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(m.cacheRoot(), 0755); err == nil {
+		_ = os.WriteFile(path, body, 0644)
+	}
+	return body, nil
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cacheTestMirror fronts a Mirror with an httptest.Server that counts how
+// many times repomd.xml and primary.xml.gz are actually fetched, and lets a
+// test swap the served primary.xml body (e.g. to simulate an upstream
+// update) between calls.
+type cacheTestMirror struct {
+	mu          sync.Mutex
+	primary     []byte
+	repomdHits  int
+	primaryHits int
+}
+
+func gzipOf(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newCacheTestMirror(t *testing.T, primaryXML []byte) (Mirror, *cacheTestMirror) {
+	t.Helper()
+	ctm := &cacheTestMirror{primary: primaryXML}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repodata/repomd.xml", func(w http.ResponseWriter, r *http.Request) {
+		ctm.mu.Lock()
+		ctm.repomdHits++
+		primary := ctm.primary
+		ctm.mu.Unlock()
+
+		compressed := gzipOf(t, primary)
+		compSum := sha256.Sum256(compressed)
+		openSum := sha256.Sum256(primary)
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<repomd>
+  <revision>1</revision>
+  <data type="primary">
+    <checksum type="sha256">%s</checksum>
+    <open-checksum type="sha256">%s</open-checksum>
+    <location href="repodata/primary.xml.gz"/>
+    <timestamp>1</timestamp>
+    <size>%d</size>
+    <open-size>%d</open-size>
+  </data>
+</repomd>`, hex.EncodeToString(compSum[:]), hex.EncodeToString(openSum[:]), len(compressed), len(primary))
+	})
+	mux.HandleFunc("/repodata/primary.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		ctm.mu.Lock()
+		ctm.primaryHits++
+		primary := ctm.primary
+		ctm.mu.Unlock()
+		w.Write(gzipOf(t, primary))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	m := Mirror{name: "test-cache-" + t.Name(), url: srv.URL, cacheDir: t.TempDir()}
+	return m, ctm
+}
+
+func (c *cacheTestMirror) hits() (repomd, primary int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.repomdHits, c.primaryHits
+}
+
+func (c *cacheTestMirror) setPrimary(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.primary = data
+}
+
+const cacheTestPrimaryV1 = `<metadata><package type="rpm">
+  <name>myapp</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="1.0.0" rel="1"/>
+  <location href="myapp-1.0.0-1.x86_64.rpm"/>
+  <format></format>
+  <checksum type="sha256" pkgid="YES">myappsum</checksum>
+</package></metadata>`
+
+const cacheTestPrimaryV2 = `<metadata><package type="rpm">
+  <name>myapp</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="2.0.0" rel="1"/>
+  <location href="myapp-2.0.0-1.x86_64.rpm"/>
+  <format></format>
+  <checksum type="sha256" pkgid="YES">myappsumv2</checksum>
+</package></metadata>`
+
+// TestRefreshSkipsRepomdRoundTripWithinMaxAge is the test for the entire
+// point of chunk0-2: once a mirror has a max age configured, a second
+// Refresh within that window must not hit the network again, and Refresh(true)
+// must always bypass the cache regardless of age.
+func TestRefreshSkipsRepomdRoundTripWithinMaxAge(t *testing.T) {
+	m, ctm := newCacheTestMirror(t, []byte(cacheTestPrimaryV1))
+	m.SetMaxAge(time.Hour)
+
+	if err := m.Refresh(false); err != nil {
+		t.Fatalf("first Refresh: %v", err)
+	}
+	if repomd, _ := ctm.hits(); repomd != 1 {
+		t.Fatalf("repomd hits after first Refresh = %d, want 1", repomd)
+	}
+
+	if err := m.Refresh(false); err != nil {
+		t.Fatalf("second Refresh: %v", err)
+	}
+	if repomd, _ := ctm.hits(); repomd != 1 {
+		t.Fatalf("repomd hits after second Refresh = %d, want 1 (cache should have been fresh)", repomd)
+	}
+
+	if err := m.Refresh(true); err != nil {
+		t.Fatalf("forced Refresh: %v", err)
+	}
+	if repomd, _ := ctm.hits(); repomd != 2 {
+		t.Fatalf("repomd hits after forced Refresh = %d, want 2 (force must bypass the cache)", repomd)
+	}
+}
+
+// TestRefreshRefetchesRepomdAfterMaxAgeExpires backdates the on-disk age file
+// past the configured max age and checks that Refresh treats the cache as
+// stale rather than trusting it forever.
+func TestRefreshRefetchesRepomdAfterMaxAgeExpires(t *testing.T) {
+	m, ctm := newCacheTestMirror(t, []byte(cacheTestPrimaryV1))
+	m.SetMaxAge(time.Hour)
+
+	if err := m.Refresh(false); err != nil {
+		t.Fatalf("first Refresh: %v", err)
+	}
+	age, ok := m.readAge()
+	if !ok {
+		t.Fatal("expected an age file to have been written")
+	}
+	age.RefreshedAt = time.Now().Add(-2 * time.Hour).Unix()
+	if err := m.writeAge(age); err != nil {
+		t.Fatalf("backdate age file: %v", err)
+	}
+
+	if err := m.Refresh(false); err != nil {
+		t.Fatalf("second Refresh: %v", err)
+	}
+	if repomd, _ := ctm.hits(); repomd != 2 {
+		t.Fatalf("repomd hits after max age expired = %d, want 2 (stale cache must be refetched)", repomd)
+	}
+}
+
+// TestGetRepoPrimarySkipsDownloadWhenChecksumUnchanged covers the other half
+// of chunk0-2's caching: even without a max age set (so repomd.xml is always
+// refetched), primary.xml.gz itself should only be re-downloaded when
+// repomd.xml reports a different checksum for it.
+func TestGetRepoPrimarySkipsDownloadWhenChecksumUnchanged(t *testing.T) {
+	m, ctm := newCacheTestMirror(t, []byte(cacheTestPrimaryV1))
+
+	first, err := m.getRepoPrimary()
+	if err != nil {
+		t.Fatalf("first getRepoPrimary: %v", err)
+	}
+	if _, primary := ctm.hits(); primary != 1 {
+		t.Fatalf("primary.xml.gz hits after first fetch = %d, want 1", primary)
+	}
+
+	second, err := m.getRepoPrimary()
+	if err != nil {
+		t.Fatalf("second getRepoPrimary: %v", err)
+	}
+	if _, primary := ctm.hits(); primary != 1 {
+		t.Fatalf("primary.xml.gz hits after second fetch = %d, want 1 (checksum unchanged, should serve cache)", primary)
+	}
+	if second.Packages[0].Version.Version != first.Packages[0].Version.Version {
+		t.Fatalf("cached primary.xml content changed unexpectedly")
+	}
+
+	ctm.setPrimary([]byte(cacheTestPrimaryV2))
+	third, err := m.getRepoPrimary()
+	if err != nil {
+		t.Fatalf("third getRepoPrimary: %v", err)
+	}
+	if _, primary := ctm.hits(); primary != 2 {
+		t.Fatalf("primary.xml.gz hits after upstream change = %d, want 2 (checksum changed, must redownload)", primary)
+	}
+	if third.Packages[0].Version.Version != "2.0.0" {
+		t.Fatalf("expected the refreshed primary.xml content, got version %q", third.Packages[0].Version.Version)
+	}
+}
@@ -0,0 +1,273 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oceanbase/obshell-sdk-go/internal/util"
+)
+
+// ResolveOptions controls how Mirror.Resolve and ResolvePackage walk a
+// package's dependency closure.
+type ResolveOptions struct {
+	// IgnoreCapability reports whether a required capability (e.g. "glibc",
+	// "libc.so.6(GLIBC_2.3)") should be skipped during resolution, typically
+	// because it is satisfied by the base OS rather than an RPM this SDK
+	// can download. A nil predicate resolves every requirement.
+	IgnoreCapability func(name string) bool
+}
+
+// resolvedPackage pairs a resolved package with the mirror it was found on,
+// since Resolve may need to look outside the mirror it was called on to
+// satisfy a transitive dependency.
+type resolvedPackage struct {
+	mirror Mirror
+	pkg    packageInfo
+}
+
+// Resolve walks entry's transitive rpm:requires closure, matching each
+// requirement against rpm:provides entries (honoring Flags/Epoch/Version/
+// Release constraints), and returns a topologically-ordered slice of
+// packageInfo - dependencies before the packages that need them - with
+// duplicates removed. Requirements not provided by this mirror are looked up
+// across DefaultMirrorRegistry before resolution fails.
+func (m Mirror) Resolve(entry PackageEntry) ([]packageInfo, error) {
+	resolved, err := m.resolveClosure(entry, ResolveOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]packageInfo, len(resolved))
+	for i, r := range resolved {
+		pkgs[i] = r.pkg
+	}
+	return pkgs, nil
+}
+
+// ResolvePackage is the registry-wide counterpart of Mirror.Resolve: it
+// tries each mirror in turn as the home of entry and resolves its dependency
+// closure from there.
+func ResolvePackage(entry PackageEntry, opts ResolveOptions) ([]packageInfo, error) {
+	resolved, err := resolveAcrossMirrors(entry, opts)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]packageInfo, len(resolved))
+	for i, r := range resolved {
+		pkgs[i] = r.pkg
+	}
+	return pkgs, nil
+}
+
+// DownloadWithDeps resolves entry's dependency closure across DefaultMirrorRegistry and
+// downloads every package in it to destDir, in topological order.
+func DownloadWithDeps(destDir string, entry PackageEntry, opts ResolveOptions) ([]string, error) {
+	resolved, err := resolveAcrossMirrors(entry, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dests := make([]string, 0, len(resolved))
+	for _, r := range resolved {
+		dest, err := r.mirror.downloadPackage(context.Background(), r.pkg, destDir, nil)
+		if err != nil {
+			return nil, err
+		}
+		dests = append(dests, dest)
+	}
+	return dests, nil
+}
+
+func resolveAcrossMirrors(entry PackageEntry, opts ResolveOptions) ([]resolvedPackage, error) {
+	for _, mirror := range DefaultMirrorRegistry.List() {
+		resolved, err := mirror.resolveClosure(entry, opts)
+		if err == nil {
+			return resolved, nil
+		}
+	}
+	return nil, fmt.Errorf("no such package: %s-%s-%s", entry.Name, entry.Version, entry.Release)
+}
+
+func (m Mirror) resolveClosure(entry PackageEntry, opts ResolveOptions) ([]resolvedPackage, error) {
+	resolved := make(map[string]resolvedPackage)
+	order := make([]string, 0)
+	visiting := make(map[string]bool)
+
+	var visit func(req PackageEntry) error
+	visit = func(req PackageEntry) error {
+		if req.Name == "" {
+			return nil
+		}
+		if opts.IgnoreCapability != nil && opts.IgnoreCapability(req.Name) {
+			return nil
+		}
+		if r, ok := resolved[req.Name]; ok {
+			if !versionSatisfies(r.pkg.Version, req) {
+				return fmt.Errorf("version conflict for %s: resolved %s-%s does not satisfy requirement %s %s",
+					req.Name, r.pkg.Version.Version, r.pkg.Version.Release, req.Flags, req.Version)
+			}
+			return nil
+		}
+		if visiting[req.Name] {
+			return nil // dependency cycle; the in-progress resolution will satisfy it
+		}
+		visiting[req.Name] = true
+		defer delete(visiting, req.Name)
+
+		mirror, pkg, err := findProvider(m, req)
+		if err != nil {
+			return err
+		}
+
+		for _, dep := range pkg.Format.Requires {
+			if providesEntry(pkg.Format.Provides, dep) {
+				continue // satisfied by the package itself (e.g. a sub-package requiring its own base)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		resolved[req.Name] = resolvedPackage{mirror: mirror, pkg: pkg}
+		order = append(order, req.Name)
+		return nil
+	}
+
+	if err := visit(entry); err != nil {
+		return nil, err
+	}
+
+	// One package can be reached through several capability names; keep only
+	// its first (earliest-resolved) occurrence.
+	out := make([]resolvedPackage, 0, len(order))
+	seen := make(map[string]bool, len(order))
+	for _, name := range order {
+		r := resolved[name]
+		key := r.mirror.name + "\x00" + r.pkg.Location.Href
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// findProvider looks for a package satisfying req, preferring mirror before
+// falling back to every mirror registered in DefaultMirrorRegistry.
+func findProvider(mirror Mirror, req PackageEntry) (Mirror, packageInfo, error) {
+	if pkg, err := findProviderOn(mirror, req); err == nil {
+		return mirror, pkg, nil
+	}
+	for _, other := range DefaultMirrorRegistry.List() {
+		if pkg, err := findProviderOn(other, req); err == nil {
+			return other, pkg, nil
+		}
+	}
+	return Mirror{}, packageInfo{}, fmt.Errorf("no such package: %s-%s-%s", req.Name, req.Version, req.Release)
+}
+
+// findProviderOn looks for a package on mirror satisfying req's version
+// constraint (per req.Flags, not a literal version match): first among
+// packages named req.Name, then among every package's rpm:provides entries,
+// so that virtual capabilities (e.g. "liboblog.so()(64bit)") resolve too.
+func findProviderOn(mirror Mirror, req PackageEntry) (packageInfo, error) {
+	if named, err := mirror.search(PackageEntry{Name: req.Name}); err == nil {
+		for _, pkg := range named {
+			if versionSatisfies(pkg.Version, req) {
+				return pkg, nil
+			}
+		}
+	}
+
+	primary, err := mirror.getRepoPrimary()
+	if err != nil {
+		return packageInfo{}, err
+	}
+	for _, pkg := range primary.Packages {
+		for _, provide := range pkg.Format.Provides {
+			if provide.Name != req.Name {
+				continue
+			}
+			if versionSatisfies(provideVersion(provide, pkg.Version), req) {
+				return pkg, nil
+			}
+		}
+	}
+	return packageInfo{}, fmt.Errorf("no such package: %s-%s-%s", req.Name, req.Version, req.Release)
+}
+
+// provideVersion returns the version a provides entry asserts: its own
+// Epoch/Version/Release when it declares one (e.g. "liboblog = 4.2.0-1"), or
+// the owning package's version for an unversioned provide.
+func provideVersion(provide PackageEntry, pkgVersion packageVersion) packageVersion {
+	if provide.Version == "" {
+		return pkgVersion
+	}
+	return packageVersion{Epoch: provide.Epoch, Version: provide.Version, Release: provide.Release}
+}
+
+// providesEntry reports whether provides contains an entry with the same
+// name as req, regardless of version.
+func providesEntry(provides []PackageEntry, req PackageEntry) bool {
+	for _, p := range provides {
+		if p.Name == req.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// versionSatisfies reports whether pv meets req's version constraint, per
+// req.Flags (EQ/GE/LE/GT/LT, the rpm:entry convention). A requirement with no
+// Version is unconstrained and always satisfied. Epoch and Release are
+// compared only when req gives them; a missing package epoch counts as "0".
+func versionSatisfies(pv packageVersion, req PackageEntry) bool {
+	if req.Version == "" {
+		return true
+	}
+
+	cmp := 0
+	if req.Epoch != "" {
+		epoch := pv.Epoch
+		if epoch == "" {
+			epoch = "0"
+		}
+		cmp = util.CmpVersionString(epoch, req.Epoch)
+	}
+	if cmp == 0 {
+		cmp = util.CmpVersionString(pv.Version, req.Version)
+	}
+	if cmp == 0 && req.Release != "" {
+		cmp = util.CmpVersionString(pv.Release, req.Release)
+	}
+	switch req.Flags {
+	case "EQ":
+		return cmp == 0
+	case "GE":
+		return cmp >= 0
+	case "LE":
+		return cmp <= 0
+	case "GT":
+		return cmp > 0
+	case "LT":
+		return cmp < 0
+	default:
+		return cmp == 0
+	}
+}
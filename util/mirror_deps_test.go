@@ -0,0 +1,197 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionSatisfies(t *testing.T) {
+	cases := []struct {
+		name string
+		pv   packageVersion
+		req  PackageEntry
+		want bool
+	}{
+		{"unconstrained", packageVersion{Version: "1.0.0"}, PackageEntry{Name: "x"}, true},
+		{"GE satisfied by newer", packageVersion{Version: "2.5.0"}, PackageEntry{Name: "x", Flags: "GE", Version: "2.0.0"}, true},
+		{"GE rejects older", packageVersion{Version: "1.0.0"}, PackageEntry{Name: "x", Flags: "GE", Version: "2.0.0"}, false},
+		{"LE rejects newer", packageVersion{Version: "2.5.0"}, PackageEntry{Name: "x", Flags: "LE", Version: "1.5.0"}, false},
+		{"LE satisfied by older", packageVersion{Version: "1.0.0"}, PackageEntry{Name: "x", Flags: "LE", Version: "1.5.0"}, true},
+		{"EQ requires exact release", packageVersion{Version: "1.0.0", Release: "1"}, PackageEntry{Name: "x", Flags: "EQ", Version: "1.0.0", Release: "2"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := versionSatisfies(c.pv, c.req); got != c.want {
+				t.Errorf("versionSatisfies(%+v, %+v) = %v, want %v", c.pv, c.req, got, c.want)
+			}
+		})
+	}
+}
+
+// newTestDepsMirror serves packagesXML (a sequence of <package> elements) as
+// the mirror's primary.xml.gz, fronted by a fixture repomd.xml whose checksums
+// actually match, so Mirror.Resolve exercises the real fetch/verify path.
+func newTestDepsMirror(t *testing.T, packagesXML string) Mirror {
+	t.Helper()
+
+	primary := []byte(fmt.Sprintf("<metadata>%s</metadata>", packagesXML))
+	openSum := sha256.Sum256(primary)
+
+	var compressedBuf bytes.Buffer
+	gz := gzip.NewWriter(&compressedBuf)
+	if _, err := gz.Write(primary); err != nil {
+		t.Fatalf("gzip primary.xml: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip writer: %v", err)
+	}
+	compressed := compressedBuf.Bytes()
+	compSum := sha256.Sum256(compressed)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repodata/repomd.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<repomd>
+  <revision>1</revision>
+  <data type="primary">
+    <checksum type="sha256">%s</checksum>
+    <open-checksum type="sha256">%s</open-checksum>
+    <location href="repodata/primary.xml.gz"/>
+    <timestamp>1</timestamp>
+    <size>%d</size>
+    <open-size>%d</open-size>
+  </data>
+</repomd>`, hex.EncodeToString(compSum[:]), hex.EncodeToString(openSum[:]), len(compressed), len(primary))
+	})
+	mux.HandleFunc("/repodata/primary.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(compressed)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return Mirror{name: "test-deps-" + t.Name(), url: srv.URL, cacheDir: t.TempDir()}
+}
+
+const depsPkgLibfoo1 = `
+<package type="rpm">
+  <name>libfoo</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="1.0.0" rel="1"/>
+  <location href="libfoo-1.0.0-1.x86_64.rpm"/>
+  <format>
+    <rpm:provides><rpm:entry name="libfoo" flags="EQ" ver="1.0.0" rel="1"/></rpm:provides>
+  </format>
+  <checksum type="sha256" pkgid="YES">libfoo1sum</checksum>
+</package>`
+
+const depsPkgLibfoo25 = `
+<package type="rpm">
+  <name>libfoo</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="2.5.0" rel="1"/>
+  <location href="libfoo-2.5.0-1.x86_64.rpm"/>
+  <format>
+    <rpm:provides><rpm:entry name="libfoo" flags="EQ" ver="2.5.0" rel="1"/></rpm:provides>
+  </format>
+  <checksum type="sha256" pkgid="YES">libfoo25sum</checksum>
+</package>`
+
+const depsPkgMyapp = `
+<package type="rpm">
+  <name>myapp</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="1.0.0" rel="1"/>
+  <location href="myapp-1.0.0-1.x86_64.rpm"/>
+  <format>
+    <rpm:provides><rpm:entry name="myapp" flags="EQ" ver="1.0.0" rel="1"/></rpm:provides>
+    <rpm:requires><rpm:entry name="libfoo" flags="GE" ver="2.0.0"/></rpm:requires>
+  </format>
+  <checksum type="sha256" pkgid="YES">myappsum</checksum>
+</package>`
+
+const depsPkgOther = `
+<package type="rpm">
+  <name>other</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="1.0.0" rel="1"/>
+  <location href="other-1.0.0-1.x86_64.rpm"/>
+  <format>
+    <rpm:provides><rpm:entry name="other" flags="EQ" ver="1.0.0" rel="1"/></rpm:provides>
+    <rpm:requires><rpm:entry name="libfoo" flags="LE" ver="1.5.0"/></rpm:requires>
+  </format>
+  <checksum type="sha256" pkgid="YES">othersum</checksum>
+</package>`
+
+const depsPkgMyappRequiresOther = `
+<package type="rpm">
+  <name>myapp</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="1.0.0" rel="1"/>
+  <location href="myapp-1.0.0-1.x86_64.rpm"/>
+  <format>
+    <rpm:provides><rpm:entry name="myapp" flags="EQ" ver="1.0.0" rel="1"/></rpm:provides>
+    <rpm:requires>
+      <rpm:entry name="libfoo" flags="GE" ver="2.0.0"/>
+      <rpm:entry name="other" flags="EQ" ver="1.0.0"/>
+    </rpm:requires>
+  </format>
+  <checksum type="sha256" pkgid="YES">myappsum</checksum>
+</package>`
+
+// TestResolveRespectsVersionFlags exercises the bug the GE requirement used to
+// trip over: findProvider must pick a candidate by evaluating req.Flags
+// against every same-named package, not by requiring a literal version match.
+// Only libfoo 2.5.0 and 1.0.0 are offered; "myapp" requires "libfoo >= 2.0.0",
+// which only 2.5.0 satisfies, even though no package carries the literal
+// string "2.0.0".
+func TestResolveRespectsVersionFlags(t *testing.T) {
+	m := newTestDepsMirror(t, depsPkgMyapp+depsPkgLibfoo1+depsPkgLibfoo25)
+
+	resolved, err := m.Resolve(PackageEntry{Name: "myapp"})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(resolved) != 2 {
+		t.Fatalf("expected 2 packages in the closure, got %d: %+v", len(resolved), resolved)
+	}
+	if resolved[0].Name != "libfoo" || resolved[0].Version.Version != "2.5.0" {
+		t.Fatalf("expected libfoo-2.5.0 resolved before myapp, got %+v", resolved[0])
+	}
+	if resolved[1].Name != "myapp" {
+		t.Fatalf("expected myapp last in topological order, got %+v", resolved[1])
+	}
+}
+
+// TestResolveDetectsVersionConflict checks that two requirers of the same
+// capability with incompatible constraints (libfoo >= 2.0.0 vs libfoo <=
+// 1.5.0) produce an error instead of silently picking one winner.
+func TestResolveDetectsVersionConflict(t *testing.T) {
+	m := newTestDepsMirror(t, depsPkgMyappRequiresOther+depsPkgOther+depsPkgLibfoo1+depsPkgLibfoo25)
+
+	if _, err := m.Resolve(PackageEntry{Name: "myapp"}); err == nil {
+		t.Fatal("expected a version conflict error, got nil")
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProgressFunc is called as a package download makes progress. bytesTotal is
+// the full size of the file being downloaded (including any bytes a resumed
+// download already had on disk), not just the bytes left to fetch.
+type ProgressFunc func(bytesDone, bytesTotal int64)
+
+const partSuffix = ".part"
+
+// downloadPackage streams pkg to destDir under ctx, resuming a previous
+// partial download (a "<file>.rpm.part" left behind by an earlier attempt)
+// with an HTTP Range request when possible, and reports progress through
+// progress if it is non-nil. When pkg carries a checksum value, the file is
+// verified against it as SHA-256 before being renamed into place, and a
+// download that fails that check never produces the final file name; a pkg
+// with an empty checksum value is renamed into place unverified.
+func (m Mirror) downloadPackage(ctx context.Context, pkg packageInfo, destDir string, progress ProgressFunc) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !filepath.IsAbs(destDir) {
+		return "", fmt.Errorf("destination is not an absolute path: %v", destDir)
+	}
+	stat, err := os.Stat(destDir)
+	if os.IsNotExist(err) {
+		if err = os.MkdirAll(destDir, fs.FileMode(0755)); err != nil {
+			return "", err
+		}
+	} else if !stat.IsDir() {
+		return "", fmt.Errorf("destination is not a directory: %v", destDir)
+	} else if err != nil {
+		return "", err
+	}
+
+	url, err := m.getLocalUrl(pkg.Location)
+	if err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(destDir, filepath.Base(pkg.Location.Href))
+	partPath := dest + partSuffix
+
+	hasher := sha256.New()
+	var offset int64
+	if partStat, err := os.Stat(partPath); err == nil {
+		offset = partStat.Size()
+		if err := hashExistingPart(partPath, hasher); err != nil {
+			offset = 0
+			hasher.Reset()
+		}
+	}
+
+	req := m.newRequest().SetDoNotParseResponse(true).SetContext(ctx)
+	if offset > 0 {
+		req.SetHeader("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resq, err := req.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resq.RawResponse.Body.Close()
+
+	openFlags := os.O_CREATE | os.O_WRONLY
+	if resq.RawResponse.StatusCode == http.StatusPartialContent && offset > 0 {
+		openFlags |= os.O_APPEND
+	} else {
+		// The server ignored our Range request (or there was nothing to
+		// resume); start the file over from scratch.
+		offset = 0
+		hasher.Reset()
+		openFlags |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(partPath, openFlags, fs.FileMode(0644))
+	if err != nil {
+		return "", err
+	}
+
+	total := offset + resq.RawResponse.ContentLength
+	var src io.Reader = resq.RawResponse.Body
+	if progress != nil {
+		src = &progressReader{r: src, done: offset, total: total, fn: progress}
+	}
+
+	_, copyErr := io.Copy(io.MultiWriter(f, hasher), src)
+	closeErr := f.Close()
+	if copyErr != nil {
+		return "", copyErr
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	if pkg.Checksum.Value != "" && !strings.EqualFold(sum, pkg.Checksum.Value) {
+		// Don't leave a corrupt prefix behind: resume always trusts and
+		// re-hashes whatever is already on disk, so a bad .part file would
+		// otherwise fail the same way on every retry forever.
+		_ = os.Remove(partPath)
+		return "", fmt.Errorf("%s: checksum mismatch: expected %s, got %s", pkg.Location.Href, pkg.Checksum.Value, sum)
+	}
+
+	if err := os.Rename(partPath, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+func hashExistingPart(path string, hasher io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(hasher, f)
+	return err
+}
+
+// progressReader wraps an io.Reader, invoking fn after every Read with the
+// running total of bytes consumed so far.
+type progressReader struct {
+	r     io.Reader
+	done  int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n > 0 {
+		p.done += int64(n)
+		p.fn(p.done, p.total)
+	}
+	return n, err
+}
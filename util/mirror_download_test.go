@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func servePayload(payload []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "pkg.rpm", time.Time{}, bytes.NewReader(payload))
+	}))
+}
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloadPackageStreamsAndVerifiesChecksum(t *testing.T) {
+	payload := bytes.Repeat([]byte("abcdefghij"), 100)
+	srv := servePayload(payload)
+	defer srv.Close()
+
+	m := Mirror{name: "test-download", url: srv.URL}
+	destDir := t.TempDir()
+	pkg := packageInfo{
+		Location: Location{Href: "pkg.rpm"},
+		Checksum: packageChecksum{Type: "sha256", Value: checksumOf(payload)},
+	}
+
+	dest, err := m.downloadPackage(context.Background(), pkg, destDir, nil)
+	if err != nil {
+		t.Fatalf("downloadPackage: %v", err)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("read downloaded file: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("downloaded content does not match the source payload")
+	}
+	if _, err := os.Stat(dest + partSuffix); !os.IsNotExist(err) {
+		t.Fatalf("expected .part file to be gone after a successful download, stat err=%v", err)
+	}
+}
+
+// TestDownloadPackageResumesPartialFile checks that a pre-existing .part file
+// is resumed with an HTTP Range request rather than re-fetched from scratch,
+// and that the reassembled file still passes the checksum check.
+func TestDownloadPackageResumesPartialFile(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 100)
+	srv := servePayload(payload)
+	defer srv.Close()
+
+	destDir := t.TempDir()
+	partPath := filepath.Join(destDir, "pkg.rpm"+partSuffix)
+	const already = 400
+	if err := os.WriteFile(partPath, payload[:already], 0644); err != nil {
+		t.Fatalf("seed partial download: %v", err)
+	}
+
+	m := Mirror{name: "test-download", url: srv.URL}
+	pkg := packageInfo{
+		Location: Location{Href: "pkg.rpm"},
+		Checksum: packageChecksum{Type: "sha256", Value: checksumOf(payload)},
+	}
+
+	var lastDone, lastTotal int64
+	progress := func(done, total int64) { lastDone, lastTotal = done, total }
+
+	dest, err := m.downloadPackage(context.Background(), pkg, destDir, progress)
+	if err != nil {
+		t.Fatalf("downloadPackage: %v", err)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("read downloaded file: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("resumed content does not match the source payload")
+	}
+	if lastTotal != int64(len(payload)) {
+		t.Fatalf("progress total = %d, want %d (should count the bytes already on disk)", lastTotal, len(payload))
+	}
+	if lastDone != int64(len(payload)) {
+		t.Fatalf("final progress done = %d, want %d", lastDone, len(payload))
+	}
+}
+
+// TestDownloadPackageDiscardsPartOnChecksumMismatch is the regression test for
+// the "stuck forever" bug: a final checksum mismatch must remove the .part
+// file so the next attempt starts clean instead of resuming the same corrupt
+// prefix again.
+func TestDownloadPackageDiscardsPartOnChecksumMismatch(t *testing.T) {
+	payload := []byte("hello world")
+	srv := servePayload(payload)
+	defer srv.Close()
+
+	destDir := t.TempDir()
+	m := Mirror{name: "test-download", url: srv.URL}
+	pkg := packageInfo{
+		Location: Location{Href: "pkg.rpm"},
+		Checksum: packageChecksum{Type: "sha256", Value: "0000000000000000000000000000000000000000000000000000000000000000"},
+	}
+
+	if _, err := m.downloadPackage(context.Background(), pkg, destDir, nil); err == nil {
+		t.Fatal("expected a checksum mismatch error, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "pkg.rpm"+partSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("expected the corrupt .part file to be removed, stat err=%v", err)
+	}
+}
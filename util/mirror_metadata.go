@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type filelistsData struct {
+	XMLName  xml.Name          `xml:"filelists"`
+	Packages []filelistPackage `xml:"package"`
+}
+
+type filelistPackage struct {
+	Pkgid   string               `xml:"pkgid,attr"`
+	Name    string               `xml:"name,attr"`
+	Arch    string               `xml:"arch,attr"`
+	Version packageVersion       `xml:"version"`
+	Files   []packageIncludeFile `xml:"file"`
+}
+
+type otherData struct {
+	XMLName  xml.Name       `xml:"otherdata"`
+	Packages []otherPackage `xml:"package"`
+}
+
+type otherPackage struct {
+	Pkgid      string           `xml:"pkgid,attr"`
+	Name       string           `xml:"name,attr"`
+	Arch       string           `xml:"arch,attr"`
+	Version    packageVersion   `xml:"version"`
+	Changelogs []ChangelogEntry `xml:"changelog"`
+}
+
+// ChangelogEntry is one <changelog author="..." date="..."> entry from
+// other.xml.
+type ChangelogEntry struct {
+	Author string `xml:"author,attr"`
+	Date   int64  `xml:"date,attr"`
+	Text   string `xml:",chardata"`
+}
+
+func (m Mirror) getRepoFilelists() (*filelistsData, error) {
+	var filelists filelistsData
+	if err := m.fetchRepoXML(FILELISTS_REPOMD_TYPE, "filelists.xml.gz", &filelists); err != nil {
+		return nil, err
+	}
+	return &filelists, nil
+}
+
+func (m Mirror) getRepoOther() (*otherData, error) {
+	var other otherData
+	if err := m.fetchRepoXML(OTHER_REPOMD_TYPE, "other.xml.gz", &other); err != nil {
+		return nil, err
+	}
+	return &other, nil
+}
+
+// WhatProvidesFile scans filelists.xml for packages that own the given
+// absolute path (e.g. "/usr/bin/observer") and returns their primary.xml
+// package entries.
+func (m Mirror) WhatProvidesFile(path string) ([]packageInfo, error) {
+	filelists, err := m.getRepoFilelists()
+	if err != nil {
+		return nil, err
+	}
+	primary, err := m.getRepoPrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	byPkgid := make(map[string]packageInfo, len(primary.Packages))
+	for _, pkg := range primary.Packages {
+		byPkgid[pkg.Checksum.Value] = pkg
+	}
+
+	matches := make([]packageInfo, 0)
+	for _, fp := range filelists.Packages {
+		if !ownsFile(fp, path) {
+			continue
+		}
+		if pkg, ok := byPkgid[fp.Pkgid]; ok {
+			matches = append(matches, pkg)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no package provides file: %s", path)
+	}
+	m.sortPackages(matches)
+	return matches, nil
+}
+
+func ownsFile(fp filelistPackage, path string) bool {
+	for _, f := range fp.Files {
+		if f.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Changelog returns the parsed <changelog> entries for the package matching
+// entry, taken from other.xml.
+func (m Mirror) Changelog(entry PackageEntry) ([]ChangelogEntry, error) {
+	matches, err := m.Search(entry)
+	if err != nil {
+		return nil, err
+	}
+	pkg := matches[0]
+
+	other, err := m.getRepoOther()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range other.Packages {
+		if op.Pkgid == pkg.Checksum.Value {
+			return op.Changelogs, nil
+		}
+	}
+	return nil, fmt.Errorf("no changelog found for %s-%s-%s", entry.Name, pkg.Version.Version, pkg.Version.Release)
+}
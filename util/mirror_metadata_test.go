@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newMetadataTestMirror serves a fixed primary/filelists/other.xml triple
+// (all keyed to the same pkgid, like a real repo) so WhatProvidesFile and
+// Changelog can be exercised end-to-end.
+func newMetadataTestMirror(t *testing.T, primaryXML, filelistsXML, otherXML string) Mirror {
+	t.Helper()
+
+	compress := func(raw []byte) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(raw); err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatalf("close gzip writer: %v", err)
+		}
+		return buf.Bytes()
+	}
+	sumHex := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+
+	type entry struct {
+		dataType, cacheName string
+		raw                 []byte
+	}
+	entries := []entry{
+		{PRIMARY_REPOMD_TYPE, "primary.xml.gz", []byte(primaryXML)},
+		{FILELISTS_REPOMD_TYPE, "filelists.xml.gz", []byte(filelistsXML)},
+		{OTHER_REPOMD_TYPE, "other.xml.gz", []byte(otherXML)},
+	}
+
+	mux := http.NewServeMux()
+	var repomdData bytes.Buffer
+	fmt.Fprint(&repomdData, `<?xml version="1.0"?><repomd><revision>1</revision>`)
+	for _, e := range entries {
+		compressed := compress(e.raw)
+		mux.HandleFunc("/repodata/"+e.cacheName, func(body []byte) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) { w.Write(body) }
+		}(compressed))
+		fmt.Fprintf(&repomdData, `<data type="%s">
+  <checksum type="sha256">%s</checksum>
+  <open-checksum type="sha256">%s</open-checksum>
+  <location href="repodata/%s"/>
+  <timestamp>1</timestamp>
+  <size>%d</size>
+  <open-size>%d</open-size>
+</data>`, e.dataType, sumHex(compressed), sumHex(e.raw), e.cacheName, len(compressed), len(e.raw))
+	}
+	fmt.Fprint(&repomdData, `</repomd>`)
+
+	mux.HandleFunc("/repodata/repomd.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(repomdData.Bytes())
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return Mirror{name: "test-metadata-" + t.Name(), url: srv.URL, cacheDir: t.TempDir()}
+}
+
+const metadataPrimaryXML = `<metadata><package type="rpm">
+  <name>myapp</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="1.0.0" rel="1"/>
+  <location href="myapp-1.0.0-1.x86_64.rpm"/>
+  <format></format>
+  <checksum type="sha256" pkgid="YES">abc123sum</checksum>
+</package></metadata>`
+
+const metadataFilelistsXML = `<filelists><package pkgid="abc123sum" name="myapp" arch="x86_64">
+  <version epoch="0" ver="1.0.0" rel="1"/>
+  <file>/usr/bin/myapp</file>
+</package></filelists>`
+
+const metadataOtherXML = `<otherdata><package pkgid="abc123sum" name="myapp" arch="x86_64">
+  <version epoch="0" ver="1.0.0" rel="1"/>
+  <changelog author="Jane Dev &lt;jane@example.invalid&gt;" date="1700000000">Initial release</changelog>
+</package></otherdata>`
+
+func TestWhatProvidesFileFindsOwningPackage(t *testing.T) {
+	m := newMetadataTestMirror(t, metadataPrimaryXML, metadataFilelistsXML, metadataOtherXML)
+
+	matches, err := m.WhatProvidesFile("/usr/bin/myapp")
+	if err != nil {
+		t.Fatalf("WhatProvidesFile: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Name != "myapp" {
+		t.Fatalf("expected exactly myapp, got %+v", matches)
+	}
+}
+
+func TestWhatProvidesFileMissingPathErrors(t *testing.T) {
+	m := newMetadataTestMirror(t, metadataPrimaryXML, metadataFilelistsXML, metadataOtherXML)
+
+	if _, err := m.WhatProvidesFile("/no/such/path"); err == nil {
+		t.Fatal("expected an error for a path no package owns, got nil")
+	}
+}
+
+func TestChangelogReturnsEntries(t *testing.T) {
+	m := newMetadataTestMirror(t, metadataPrimaryXML, metadataFilelistsXML, metadataOtherXML)
+
+	entries, err := m.Changelog(PackageEntry{Name: "myapp"})
+	if err != nil {
+		t.Fatalf("Changelog: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 changelog entry, got %d", len(entries))
+	}
+	if entries[0].Author != "Jane Dev <jane@example.invalid>" || entries[0].Text != "Initial release" {
+		t.Fatalf("unexpected changelog entry: %+v", entries[0])
+	}
+}
+
+func TestChangelogMissingPackageErrors(t *testing.T) {
+	m := newMetadataTestMirror(t, metadataPrimaryXML, metadataFilelistsXML, metadataOtherXML)
+
+	if _, err := m.Changelog(PackageEntry{Name: "doesnotexist"}); err == nil {
+		t.Fatal("expected an error for a package that doesn't exist, got nil")
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// MirrorRegistry holds the set of mirrors DownloadPackage, SearchPackage and
+// ResolvePackage consult, in registration order. It is safe for concurrent
+// use.
+type MirrorRegistry struct {
+	mu      sync.RWMutex
+	mirrors map[string]*Mirror
+	order   []string
+}
+
+// NewMirrorRegistry returns an empty mirror registry.
+func NewMirrorRegistry() *MirrorRegistry {
+	return &MirrorRegistry{mirrors: make(map[string]*Mirror)}
+}
+
+// Register adds mirror to the registry, or replaces the existing entry with
+// the same name in place.
+func (r *MirrorRegistry) Register(mirror Mirror) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.mirrors[mirror.name]; !exists {
+		r.order = append(r.order, mirror.name)
+	}
+	r.mirrors[mirror.name] = &mirror
+}
+
+// Get returns a pointer to the registry's own copy of the named mirror, so
+// callers can reconfigure it in place (e.g. SetMaxAge, SetCacheDir) and have
+// DownloadPackage/SearchPackage/ResolvePackage see the change - unlike List,
+// which hands out copies.
+//
+// The registry's mutex only protects map membership (Register/Unregister/
+// Get/List itself), not the fields of a *Mirror handed out by Get: List
+// concurrently dereferences that same pointer without taking a lock on it.
+// Reconfigure a mirror returned by Get before any concurrent Search/
+// Download/Resolve traffic against the registry begins, not while it's
+// live.
+func (r *MirrorRegistry) Get(name string) (*Mirror, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.mirrors[name]
+	return m, ok
+}
+
+// Unregister removes the mirror with the given name, if any.
+func (r *MirrorRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.mirrors[name]; !exists {
+		return
+	}
+	delete(r.mirrors, name)
+	for i, n := range r.order {
+		if n == name {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// List returns a snapshot copy of the registered mirrors in registration
+// order. Mutating an entry returned by List does not affect the registry;
+// use Get to reconfigure a registered mirror in place.
+func (r *MirrorRegistry) List() []Mirror {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Mirror, 0, len(r.order))
+	for _, name := range r.order {
+		out = append(out, *r.mirrors[name])
+	}
+	return out
+}
+
+// DefaultMirrorRegistry is the registry DownloadPackage, SearchPackage and
+// ResolvePackage consult. It is seeded with OB_MIRRORS during package init;
+// callers standing up a private or authenticated registry (Gitea/Forgejo,
+// Nexus, JFrog) can Register additional mirrors here, or build their own
+// MirrorRegistry and call Mirror methods directly.
+var DefaultMirrorRegistry = NewMirrorRegistry()
+
+// Name returns the mirror's registry key, e.g. "OceanBase-community-stable-el8".
+// Use it with MirrorRegistry.Get to reconfigure a registered mirror in place.
+func (m Mirror) Name() string {
+	return m.name
+}
+
+// WithBasicAuth returns a copy of the mirror that sends user/pass as HTTP
+// basic auth on every request.
+func (m Mirror) WithBasicAuth(user, pass string) Mirror {
+	m.basicAuthUser = user
+	m.basicAuthPass = pass
+	return m
+}
+
+// WithBearerToken returns a copy of the mirror that sends tok as a bearer
+// token on every request.
+func (m Mirror) WithBearerToken(tok string) Mirror {
+	m.bearerToken = tok
+	return m
+}
+
+// WithHTTPClient returns a copy of the mirror that issues requests through
+// client instead of resty's default client, e.g. to reuse connection pooling
+// or a custom transport.
+func (m Mirror) WithHTTPClient(client *http.Client) Mirror {
+	m.httpClient = client
+	return m
+}
+
+// WithTLSConfig returns a copy of the mirror that uses config for TLS
+// connections, e.g. to trust a private registry's custom CA.
+//
+// If this mirror also has a WithHTTPClient set, config only takes effect
+// when that client's Transport is a *http.Transport - resty's
+// SetTLSClientConfig silently no-ops otherwise. Bake the TLS config into the
+// http.Client passed to WithHTTPClient if it uses a custom RoundTripper.
+func (m Mirror) WithTLSConfig(config *tls.Config) Mirror {
+	m.tlsConfig = config
+	return m
+}
+
+// WithHeaders returns a copy of the mirror that sends the given headers on
+// every request, in addition to any auth headers.
+func (m Mirror) WithHeaders(headers map[string]string) Mirror {
+	m.headers = make(map[string]string, len(headers))
+	for k, v := range headers {
+		m.headers[k] = v
+	}
+	return m
+}
+
+// newRequest builds a resty request carrying the mirror's configured
+// authentication, headers and transport. Every HTTP call the mirror makes
+// (repomd.xml, repomd.xml.asc, primary/filelists/other.xml.gz, package
+// downloads) must be issued through this method rather than a bare
+// resty.New().R() so private/authenticated registries work everywhere.
+func (m Mirror) newRequest() *resty.Request {
+	client := resty.New()
+	if m.httpClient != nil {
+		client = resty.NewWithClient(m.httpClient)
+	}
+	if m.tlsConfig != nil {
+		client.SetTLSClientConfig(m.tlsConfig)
+	}
+
+	req := client.R()
+	if m.basicAuthUser != "" {
+		req.SetBasicAuth(m.basicAuthUser, m.basicAuthPass)
+	}
+	if m.bearerToken != "" {
+		req.SetAuthToken(m.bearerToken)
+	}
+	if len(m.headers) > 0 {
+		req.SetHeaders(m.headers)
+	}
+	return req
+}
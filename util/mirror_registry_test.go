@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newHeaderCapturingServer(t *testing.T, captured *http.Header) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*captured = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNewRequestSendsBasicAuth(t *testing.T) {
+	var got http.Header
+	srv := newHeaderCapturingServer(t, &got)
+
+	m := Mirror{}.WithBasicAuth("alice", "s3cret")
+	if _, err := m.newRequest().Get(srv.URL); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	user, pass, ok := (&http.Request{Header: got}).BasicAuth()
+	if !ok || user != "alice" || pass != "s3cret" {
+		t.Fatalf("Authorization header = %q, want basic auth for alice:s3cret", got.Get("Authorization"))
+	}
+}
+
+func TestNewRequestSendsBearerToken(t *testing.T) {
+	var got http.Header
+	srv := newHeaderCapturingServer(t, &got)
+
+	m := Mirror{}.WithBearerToken("tok-123")
+	if _, err := m.newRequest().Get(srv.URL); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	if want := "Bearer tok-123"; got.Get("Authorization") != want {
+		t.Fatalf("Authorization header = %q, want %q", got.Get("Authorization"), want)
+	}
+}
+
+func TestNewRequestSendsCustomHeaders(t *testing.T) {
+	var got http.Header
+	srv := newHeaderCapturingServer(t, &got)
+
+	m := Mirror{}.WithHeaders(map[string]string{"X-Registry-Token": "abc"})
+	if _, err := m.newRequest().Get(srv.URL); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	if want := "abc"; got.Get("X-Registry-Token") != want {
+		t.Fatalf("X-Registry-Token header = %q, want %q", got.Get("X-Registry-Token"), want)
+	}
+}
+
+// TestNewRequestAppliesTLSConfig proves WithTLSConfig actually reaches the
+// transport: without it, a request to a self-signed httptest.NewTLSServer
+// fails certificate verification; with InsecureSkipVerify set, it succeeds.
+func TestNewRequestAppliesTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	var plain Mirror
+	if _, err := plain.newRequest().Get(srv.URL); err == nil {
+		t.Fatal("expected a certificate verification error without WithTLSConfig, got nil")
+	}
+
+	insecure := Mirror{}.WithTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	if _, err := insecure.newRequest().Get(srv.URL); err != nil {
+		t.Fatalf("request with InsecureSkipVerify should succeed against a self-signed server: %v", err)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// TestNewRequestTLSConfigNoopsWithNonTransportHTTPClient pins down the
+// documented WithHTTPClient/WithTLSConfig interaction: resty's
+// SetTLSClientConfig only mutates a *http.Transport, so a WithHTTPClient
+// whose RoundTripper isn't one silently ignores WithTLSConfig. Callers that
+// need both must bake the TLS config into the http.Client they pass to
+// WithHTTPClient instead.
+func TestNewRequestTLSConfigNoopsWithNonTransportHTTPClient(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	m := Mirror{}.WithHTTPClient(client).WithTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	if _, err := m.newRequest().Get(srv.URL); err == nil {
+		t.Fatal("expected WithTLSConfig to be ignored for a non-*http.Transport client, but the request succeeded")
+	}
+}
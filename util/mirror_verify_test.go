@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2024 OceanBase.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("hello world")
+	sum := sha256.Sum256(data)
+	valid := hex.EncodeToString(sum[:])
+
+	if err := verifyChecksum(data, repoChecksum{Type: "sha256", Value: valid}); err != nil {
+		t.Fatalf("valid checksum rejected: %v", err)
+	}
+	if err := verifyChecksum(data, repoChecksum{}); err != nil {
+		t.Fatalf("empty checksum (nothing to verify against) should not error: %v", err)
+	}
+	if err := verifyChecksum(data, repoChecksum{Type: "sha256", Value: "deadbeef"}); err == nil {
+		t.Fatal("expected checksum mismatch error, got nil")
+	}
+	if err := verifyChecksum(data, repoChecksum{Type: "md5", Value: "deadbeef"}); err == nil {
+		t.Fatal("expected error for unsupported checksum type, got nil")
+	}
+}
+
+// testSigningKey generates an ephemeral PGP entity and returns its armored
+// public key alongside a signer usable with ArmoredDetachSign.
+func testSigningKey(t *testing.T) (armoredPubKey string, entity *openpgp.Entity) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("obshell-sdk-go test", "", "test@example.invalid", nil)
+	if err != nil {
+		t.Fatalf("generate test key: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("open armor writer: %v", err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatalf("serialize public key: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close armor writer: %v", err)
+	}
+	return buf.String(), entity
+}
+
+func TestVerifyRepomdSignature(t *testing.T) {
+	pubKey, entity := testSigningKey(t)
+	repomd := []byte(`<repomd><revision>1</revision></repomd>`)
+
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, entity, bytes.NewReader(repomd), nil); err != nil {
+		t.Fatalf("sign repomd: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(sig.Bytes())
+	}))
+	defer srv.Close()
+
+	m := Mirror{name: "test-mirror", url: srv.URL, gpgKey: pubKey}
+
+	if err := m.verifyRepomdSignature(repomd); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := m.verifyRepomdSignature([]byte(`<repomd><revision>2</revision></repomd>`)); err == nil {
+		t.Fatal("expected signature verification to fail for tampered repomd, got nil")
+	}
+
+	unpinned := Mirror{name: "test-mirror-no-key", url: srv.URL}
+	if err := unpinned.verifyRepomdSignature(repomd); err != nil {
+		t.Fatalf("mirror with no pinned key should skip verification, got: %v", err)
+	}
+}
+
+func TestVerifyRepomdSignatureRejectsInvalidKey(t *testing.T) {
+	m := Mirror{name: "test-mirror", url: "http://example.invalid", gpgKey: "not a real armored key"}
+	if err := m.verifyRepomdSignature([]byte("anything")); err == nil {
+		t.Fatal("expected error for unparseable pinned key, got nil")
+	}
+}
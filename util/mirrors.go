@@ -19,26 +19,28 @@ package util
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"encoding/xml"
 	"fmt"
-	"io/fs"
+	"io"
+	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
 	"strings"
-
-	"github.com/go-resty/resty/v2"
+	"time"
 
 	"github.com/oceanbase/obshell-sdk-go/internal/util"
 )
 
 const (
-	REMOTE_REPOMD_FILE  = "/repodata/repomd.xml"
-	REPO_AGE_FILE       = ".rege_age"
-	PRIMARY_REPOMD_TYPE = "primary"
+	REMOTE_REPOMD_FILE    = "/repodata/repomd.xml"
+	REPO_AGE_FILE         = ".rege_age"
+	PRIMARY_REPOMD_TYPE   = "primary"
+	FILELISTS_REPOMD_TYPE = "filelists"
+	OTHER_REPOMD_TYPE     = "other"
 )
 
 type Mirror struct {
@@ -46,6 +48,17 @@ type Mirror struct {
 	url    string
 	arch   string
 	nonLse bool
+	gpgKey string
+
+	cacheDir string
+	maxAge   time.Duration
+
+	basicAuthUser string
+	basicAuthPass string
+	bearerToken   string
+	httpClient    *http.Client
+	tlsConfig     *tls.Config
+	headers       map[string]string
 }
 
 type baseMirror struct {
@@ -83,12 +96,21 @@ type repoMD struct {
 }
 
 type repoData struct {
-	Type            string   `xml:"type,attr"`
-	Location        Location `xml:"location"`
-	Timestamp       int      `xml:"timestamp"`
-	Size            int      `xml:"size"`
-	OpenSize        int      `xml:"open-size"`
-	DatabaseVersion int      `xml:"database_version,omitempty"` // Optional field
+	Type            string       `xml:"type,attr"`
+	Checksum        repoChecksum `xml:"checksum"`
+	OpenChecksum    repoChecksum `xml:"open-checksum"`
+	Location        Location     `xml:"location"`
+	Timestamp       int          `xml:"timestamp"`
+	Size            int          `xml:"size"`
+	OpenSize        int          `xml:"open-size"`
+	DatabaseVersion int          `xml:"database_version,omitempty"` // Optional field
+}
+
+// repoChecksum represents a <checksum>/<open-checksum> element of repomd.xml,
+// e.g. <checksum type="sha256">...</checksum>.
+type repoChecksum struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
 }
 
 type primaryData struct {
@@ -96,16 +118,25 @@ type primaryData struct {
 }
 
 type packageInfo struct {
-	XMLName  xml.Name       `xml:"package"`
-	Name     string         `xml:"name"`
-	Arch     string         `xml:"arch"`
-	Version  packageVersion `xml:"version"`
-	Packager string         `xml:"packager"`
-	URL      string         `xml:"url"`
-	Time     packageTime    `xml:"time"`
-	Size     packageSize    `xml:"size"`
-	Location Location       `xml:"location"`
-	Format   packageFormat  `xml:"format"`
+	XMLName  xml.Name        `xml:"package"`
+	Name     string          `xml:"name"`
+	Arch     string          `xml:"arch"`
+	Version  packageVersion  `xml:"version"`
+	Packager string          `xml:"packager"`
+	URL      string          `xml:"url"`
+	Time     packageTime     `xml:"time"`
+	Size     packageSize     `xml:"size"`
+	Location Location        `xml:"location"`
+	Format   packageFormat   `xml:"format"`
+	Checksum packageChecksum `xml:"checksum"`
+}
+
+// packageChecksum represents the <checksum> element of a <package> entry in
+// primary.xml, e.g. <checksum type="sha256" pkgid="YES">...</checksum>.
+type packageChecksum struct {
+	Type  string `xml:"type,attr"`
+	Pkgid string `xml:"pkgid,attr,omitempty"`
+	Value string `xml:",chardata"`
 }
 
 type packageVersion struct {
@@ -130,15 +161,20 @@ type Location struct {
 	Href    string `xml:"href,attr"`
 }
 
+// packageFormat's fields are all declared in the "rpm:" namespace in a real
+// primary.xml (e.g. <rpm:provides>), but encoding/xml's ">"-chained tag
+// matching only ever compares local names and ignores namespace entirely, so
+// the struct tags below deliberately omit the "rpm:" prefix - adding it back
+// would make every one of these fields silently decode as empty.
 type packageFormat struct {
-	License     string               `xml:"rpm:license"`
-	Vendor      string               `xml:"rpm:vendor"`
-	Group       string               `xml:"rpm:group"`
-	BuildHost   string               `xml:"rpm:buildhost"`
-	SourceRPM   string               `xml:"rpm:sourcerpm"`
-	HeaderRange packageHeaderRange   `xml:"rpm:header-range"`
-	Provides    []PackageEntry       `xml:"rpm:provides>rpm:entry"`
-	Requires    []PackageEntry       `xml:"rpm:requires>rpm:entry"`
+	License     string               `xml:"license"`
+	Vendor      string               `xml:"vendor"`
+	Group       string               `xml:"group"`
+	BuildHost   string               `xml:"buildhost"`
+	SourceRPM   string               `xml:"sourcerpm"`
+	HeaderRange packageHeaderRange   `xml:"header-range"`
+	Provides    []PackageEntry       `xml:"provides>entry"`
+	Requires    []PackageEntry       `xml:"requires>entry"`
 	Files       []packageIncludeFile `xml:"file"`
 }
 
@@ -217,29 +253,44 @@ func init() {
 		release = EL8
 	}
 
+	// No GPG key is pinned by default: OceanBase does not currently publish a
+	// verified detached-signature key for mirrors.oceanbase.com. Callers who
+	// have a trusted key for their own registry should opt in explicitly with
+	// Mirror.WithGPGKey; shipping an unverifiable placeholder here would make
+	// every Search/Download against the default mirrors fail closed.
 	OB_COMMUNITY_STABLE_MIRROR = OB_COMMUNITY_STABLE_BASE.GetMirror(arch, release)
 	OB_DEVELOPMENT_KIT_MIRROR = OB_DEVELOPMENT_KIT_BASE.GetMirror(arch, release)
 	OB_MIRRORS = []Mirror{OB_COMMUNITY_STABLE_MIRROR, OB_DEVELOPMENT_KIT_MIRROR}
+
+	for _, mirror := range OB_MIRRORS {
+		DefaultMirrorRegistry.Register(mirror)
+	}
 }
 
 func (m Mirror) getRepomdUrl() (string, error) {
 	return url.JoinPath(m.url, REMOTE_REPOMD_FILE)
 }
 
-func (m Mirror) getRepoMD() (repo *repoMD, err error) {
-	url, err := m.getRepomdUrl()
-	if err != nil {
-		return nil, err
-	}
+func (m Mirror) getRepomdSignatureUrl() (string, error) {
+	return url.JoinPath(m.url, REMOTE_REPOMD_FILE+".asc")
+}
+
+// WithGPGKey returns a copy of the mirror that requires repomd.xml to carry a
+// valid detached PGP signature from armoredPubKey before it is trusted. Once
+// set, getRepoMD refuses to use the mirror if the signature is missing or
+// does not verify.
+func (m Mirror) WithGPGKey(armoredPubKey string) Mirror {
+	m.gpgKey = armoredPubKey
+	return m
+}
 
-	req := resty.New().R()
-	resq, err := req.Get(url)
+func (m Mirror) getRepoMD() (repo *repoMD, err error) {
+	body, err := m.refreshRepomd(false)
 	if err != nil {
 		return nil, err
 	}
-	defer resq.RawResponse.Body.Close()
 
-	xml.Unmarshal(resq.Body(), &repo)
+	xml.Unmarshal(body, &repo)
 	return
 }
 
@@ -251,75 +302,65 @@ func (m Mirror) getLocalUrl(location Location) (string, error) {
 	}
 }
 
-func (m Mirror) getRepoPrimaryUrl() (string, error) {
+func (m Mirror) getRepoData(dataType string) (*repoData, error) {
 	repo, err := m.getRepoMD()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	for _, data := range repo.Data {
-		if data.Type == PRIMARY_REPOMD_TYPE {
-			return m.getLocalUrl(data.Location)
+		if data.Type == dataType {
+			return &data, nil
 		}
 	}
-	return "", fmt.Errorf("primary repomd not found")
+	return nil, fmt.Errorf("%s repomd not found", dataType)
 }
 
-func (m Mirror) getRepoPrimary() (*primaryData, error) {
-	url, err := m.getRepoPrimaryUrl()
+func (m Mirror) getRepoPrimaryUrl() (string, error) {
+	data, err := m.getRepoData(PRIMARY_REPOMD_TYPE)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	return m.getLocalUrl(data.Location)
+}
 
-	req := resty.New().R()
-	resq, err := req.Get(url)
+// fetchRepoXML loads the repomd-advertised data block of the given type
+// (e.g. "primary", "filelists", "other"), serving the gzip-compressed file
+// from the on-disk cache when possible, verifying both its compressed and
+// decompressed checksums, and unmarshalling the decompressed XML into out.
+func (m Mirror) fetchRepoXML(dataType, cacheName string, out interface{}) error {
+	data, err := m.getRepoData(dataType)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	buf := bytes.NewBuffer(resq.Body())
-	gzipReader, err := gzip.NewReader(buf)
+	compressed, err := m.loadCachedRepoFile(cacheName, data.Location, data.Checksum)
 	if err != nil {
-		return nil, err
-	}
-	defer gzipReader.Close()
-
-	var packages primaryData
-	err = xml.NewDecoder(gzipReader).Decode(&packages)
-	return &packages, err
-}
-
-func (m Mirror) downloadPackage(packageInfo packageInfo, destDir string) (string, error) {
-	if !filepath.IsAbs(destDir) {
-		return "", fmt.Errorf("destination is not an absolute path: %v", destDir)
-	}
-	stat, err := os.Stat(destDir)
-	if os.IsNotExist(err) {
-		if err = os.MkdirAll(destDir, fs.FileMode(0755)); err != nil {
-			return "", err
-		}
-	} else if !stat.IsDir() {
-		return "", fmt.Errorf("destination is not a directory: %v", destDir)
-	} else if err != nil {
-		return "", err
+		return fmt.Errorf("%s: %w", cacheName, err)
 	}
 
-	url, err := m.getLocalUrl(packageInfo.Location)
+	gzipReader, err := gzip.NewReader(bytes.NewReader(compressed))
 	if err != nil {
-		return "", err
+		return err
 	}
+	defer gzipReader.Close()
 
-	req := resty.New().R()
-	resq, err := req.Get(url)
+	decompressed, err := io.ReadAll(gzipReader)
 	if err != nil {
-		return "", err
+		return err
+	}
+	if err := verifyChecksum(decompressed, data.OpenChecksum); err != nil {
+		return fmt.Errorf("%s: %w", cacheName, err)
 	}
-	defer resq.RawResponse.Body.Close()
 
-	dest := filepath.Join(destDir, filepath.Base(packageInfo.Location.Href))
-	if err = writeFileLocal(resq.Body(), dest, fs.FileMode(0644)); err != nil {
-		return "", err
+	return xml.Unmarshal(decompressed, out)
+}
+
+func (m Mirror) getRepoPrimary() (*primaryData, error) {
+	var packages primaryData
+	if err := m.fetchRepoXML(PRIMARY_REPOMD_TYPE, "primary.xml.gz", &packages); err != nil {
+		return nil, err
 	}
-	return dest, nil
+	return &packages, nil
 }
 
 // Download searches for the specified package entry in the mirror and downloads the first match to the destination directory.
@@ -330,7 +371,19 @@ func (m Mirror) Download(destDir string, entry PackageEntry) (string, error) {
 		return "", err
 	}
 	// If err is nil, then packages must not be nil
-	return m.downloadPackage(packages[0], destDir)
+	return m.downloadPackage(context.Background(), packages[0], destDir, nil)
+}
+
+// DownloadWithProgress behaves like Download, but streams the package to
+// destDir under ctx and reports progress through progress (see
+// Mirror.downloadPackage for resume/verification behavior). A nil progress is
+// allowed and simply disables progress reporting.
+func (m Mirror) DownloadWithProgress(ctx context.Context, destDir string, entry PackageEntry, progress ProgressFunc) (string, error) {
+	packages, err := m.Search(entry)
+	if err != nil {
+		return "", err
+	}
+	return m.downloadPackage(ctx, packages[0], destDir, progress)
 }
 
 // Search looks for packages that match the provided package entry within the mirror.
@@ -400,27 +453,28 @@ func (m Mirror) sortPackages(packages []packageInfo) {
 	})
 }
 
-// DownloadPackage searches for the specified package entry across all mirrors defined in OB_MIRRORS
-// and downloads the first match to the destination directory.
+// DownloadPackage searches for the specified package entry across every mirror
+// in DefaultMirrorRegistry and downloads the first match to the destination directory.
 // If no matching package is found in any mirror, or an error occurs, it returns an error.
 func DownloadPackage(destDir string, entry PackageEntry) (string, error) {
-	for _, mirror := range OB_MIRRORS {
+	for _, mirror := range DefaultMirrorRegistry.List() {
 		packages, err := mirror.search(entry)
 		if err != nil {
 			return "", err
 		}
 
 		if len(packages) > 0 {
-			return mirror.downloadPackage(packages[0], destDir)
+			return mirror.downloadPackage(context.Background(), packages[0], destDir, nil)
 		}
 	}
 	return "", fmt.Errorf("no such package: %s-%s-%s", entry.Name, entry.Version, entry.Release)
 }
 
-// SearchPackage searches for packages that match the provided package entry across all mirrors defined in OB_MIRRORS.
+// SearchPackage searches for packages that match the provided package entry
+// across every mirror in DefaultMirrorRegistry.
 // If no matching packages are found, or an error occurs during the search, it returns an error.
 func SearchPackage(entry PackageEntry) ([]packageInfo, error) {
-	for _, mirror := range OB_MIRRORS {
+	for _, mirror := range DefaultMirrorRegistry.List() {
 		packages, err := mirror.search(entry)
 		if err != nil {
 			return nil, err